@@ -0,0 +1,67 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package audit provides a structured audit-log sink for HTTP requests
+// traced with ctxtrace, with pluggable sampling so that, for example,
+// testing-prefixed traces can be dropped automatically.
+package audit
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Event describes a single HTTP request for audit logging purposes.
+type Event struct {
+	// TraceID is the ctxtrace trace ID for the request.
+	TraceID string
+	// Method is the HTTP method of the request.
+	Method string
+	// Path is the request URL path.
+	Path string
+	// Status is the HTTP status code written to the response.
+	Status int
+	// Latency is how long the request took to handle.
+	Latency time.Duration
+	// CapturedHeaders holds the header values ctxtrace captured from the
+	// request, if any were configured with ctxtrace.SetCapturedHeaders.
+	CapturedHeaders map[string]string
+	// Testing reports whether TraceID was created with
+	// ctxtrace.WithTestingTraceID.
+	Testing bool
+}
+
+// Auditor records audit events, e.g. to a structured log sink.
+type Auditor interface {
+	Record(ctx context.Context, event Event)
+}
+
+// Sampler decides whether a given event should be recorded.
+type Sampler func(event Event) bool
+
+// AlwaysSample is a Sampler that records every event.
+func AlwaysSample(Event) bool {
+	return true
+}
+
+// RatioSampler returns a Sampler that records events with probability p,
+// clamped to [0, 1].
+func RatioSampler(p float64) Sampler {
+	if p < 0 {
+		p = 0
+	}
+	if p > 1 {
+		p = 1
+	}
+	return func(Event) bool {
+		return rand.Float64() < p
+	}
+}
+
+// NeverSampleTesting is a Sampler that drops events whose trace ID was
+// created with ctxtrace.WithTestingTraceID, so that testing requests are
+// excluded from the audit stream automatically.
+func NeverSampleTesting(event Event) bool {
+	return !event.Testing
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package audit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/canonical/ctxtrace"
+	"github.com/canonical/ctxtrace/audit"
+)
+
+type recordingAuditor struct {
+	events []audit.Event
+}
+
+func (a *recordingAuditor) Record(ctx context.Context, event audit.Event) {
+	a.events = append(a.events, event)
+}
+
+func TestMiddlewareRecordsEvent(t *testing.T) {
+	c := qt.New(t)
+	auditor := &recordingAuditor{}
+	middleware := audit.Middleware(auditor, nil)
+
+	srv := httptest.NewServer(ctxtrace.Handler(middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))))
+	defer srv.Close()
+
+	_, err := http.DefaultClient.Get(srv.URL)
+	c.Assert(err, qt.IsNil)
+	c.Assert(auditor.events, qt.HasLen, 1)
+	c.Assert(auditor.events[0].Status, qt.Equals, http.StatusTeapot)
+	c.Assert(auditor.events[0].Method, qt.Equals, "GET")
+}
+
+func TestNeverSampleTestingDropsTestingTraces(t *testing.T) {
+	c := qt.New(t)
+	c.Assert(audit.NeverSampleTesting(audit.Event{Testing: true}), qt.Equals, false)
+	c.Assert(audit.NeverSampleTesting(audit.Event{Testing: false}), qt.Equals, true)
+}
+
+func TestRatioSamplerBounds(t *testing.T) {
+	c := qt.New(t)
+	always := audit.RatioSampler(1)
+	c.Assert(always(audit.Event{}), qt.Equals, true)
+	never := audit.RatioSampler(0)
+	c.Assert(never(audit.Event{}), qt.Equals, false)
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package audit
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/canonical/ctxtrace"
+)
+
+type fieldsContextKey struct{}
+
+// WithFields attaches zap fields to ctx so that a context-aware logger, such
+// as the existing juju/zaputil integration, can include them without the
+// handler having to repeat them on every log call.
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	return context.WithValue(ctx, fieldsContextKey{}, fields)
+}
+
+// FieldsFromContext returns the zap fields attached to ctx by WithFields, or
+// nil if none were attached.
+func FieldsFromContext(ctx context.Context) []zap.Field {
+	fields, _ := ctx.Value(fieldsContextKey{}).([]zap.Field)
+	return fields
+}
+
+// Middleware returns HTTP middleware that records one Event per request with
+// auditor, after checking it against sampler. A nil sampler defaults to
+// AlwaysSample. It also enriches the request context with a zap.Field slice
+// (trace_id plus any captured headers) so downstream handlers log with the
+// same trace_id key ctxtrace itself uses, without extra boilerplate.
+func Middleware(auditor Auditor, sampler Sampler) func(http.Handler) http.Handler {
+	if sampler == nil {
+		sampler = AlwaysSample
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			ctx := r.Context()
+			traceID := ctxtrace.TraceIDFromContext(ctx)
+			fields := append([]zap.Field{zap.String(ctxtrace.TraceIDCtx, traceID)}, ctxtrace.CapturedHeaderFields(ctx)...)
+			ctx = WithFields(ctx, fields...)
+			r = r.WithContext(ctx)
+
+			h.ServeHTTP(rec, r)
+
+			event := Event{
+				TraceID:         traceID,
+				Method:          r.Method,
+				Path:            r.URL.Path,
+				Status:          rec.status,
+				Latency:         time.Since(start),
+				CapturedHeaders: ctxtrace.CapturedHeadersFromContext(ctx),
+				Testing:         ctxtrace.IsTestingTraceID(traceID),
+			}
+			if sampler(event) {
+				auditor.Record(ctx, event)
+			}
+		})
+	}
+}
+
+// statusRecorder wraps an http.ResponseWriter to capture the status code
+// written to the response, defaulting to http.StatusOK when WriteHeader is
+// never called explicitly.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package audit
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/canonical/ctxtrace"
+)
+
+// ZapAuditor records audit events as structured log entries through a
+// zap.Logger, using the same trace_id key as the rest of the juju/zaputil
+// integration.
+type ZapAuditor struct {
+	// Logger is the zap.Logger events are recorded to. If nil, zap.L() is
+	// used instead.
+	Logger *zap.Logger
+}
+
+// Record implements Auditor.
+func (a ZapAuditor) Record(ctx context.Context, event Event) {
+	logger := a.Logger
+	if logger == nil {
+		logger = zap.L()
+	}
+
+	fields := make([]zap.Field, 0, 6+len(event.CapturedHeaders))
+	fields = append(fields,
+		zap.String(ctxtrace.TraceIDCtx, event.TraceID),
+		zap.String("method", event.Method),
+		zap.String("path", event.Path),
+		zap.Int("status", event.Status),
+		zap.Duration("latency", event.Latency),
+		zap.Bool("testing", event.Testing),
+	)
+	for k, v := range event.CapturedHeaders {
+		fields = append(fields, zap.String(k, v))
+	}
+	logger.Info("http request", fields...)
+}
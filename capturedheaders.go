@@ -0,0 +1,72 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package ctxtrace
+
+import (
+	"context"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+type capturedHeadersContextKey struct{}
+
+// capturedHeaderNames holds the header names that Handler lifts from an
+// incoming request into the context. Set it with SetCapturedHeaders.
+var capturedHeaderNames []string
+
+// SetCapturedHeaders configures the list of request header names that
+// Handler captures from incoming requests and attaches to the context
+// alongside the trace ID, e.g. "X-CustomHeader", "X-Forwarded-For" or
+// "User-Agent". Passing nil disables header capturing.
+func SetCapturedHeaders(names []string) {
+	capturedHeaderNames = names
+}
+
+// WithCapturedHeaders attaches the given captured header key/value pairs to
+// ctx so that they can be re-emitted on outbound requests by
+// Transport.RoundTrip or logged with CapturedHeaderFields.
+func WithCapturedHeaders(ctx context.Context, headers map[string]string) context.Context {
+	return context.WithValue(ctx, capturedHeadersContextKey{}, headers)
+}
+
+// CapturedHeadersFromContext returns the captured headers stored in ctx, or
+// nil if none were captured.
+func CapturedHeadersFromContext(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(capturedHeadersContextKey{}).(map[string]string)
+	return headers
+}
+
+// CapturedHeaderFields returns the captured headers in ctx as zap.Field
+// values, so that services using the existing juju/zaputil integration can
+// log them alongside trace_id without extra boilerplate.
+func CapturedHeaderFields(ctx context.Context) []zap.Field {
+	headers := CapturedHeadersFromContext(ctx)
+	if len(headers) == 0 {
+		return nil
+	}
+	fields := make([]zap.Field, 0, len(headers))
+	for k, v := range headers {
+		fields = append(fields, zap.String(k, v))
+	}
+	return fields
+}
+
+// captureHeaders extracts the given header names from header, skipping any
+// that are absent, and returns nil if none of them were present.
+func captureHeaders(header http.Header, names []string) map[string]string {
+	if len(names) == 0 {
+		return nil
+	}
+	captured := make(map[string]string, len(names))
+	for _, name := range names {
+		if v := header.Get(name); v != "" {
+			captured[name] = v
+		}
+	}
+	if len(captured) == 0 {
+		return nil
+	}
+	return captured
+}
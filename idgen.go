@@ -0,0 +1,87 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package ctxtrace
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/xid"
+)
+
+// IDGenerator generates trace ID strings. The built-in UUIDv4Generator,
+// UUIDv7Generator and XIDGenerator cover the common choices; callers can
+// implement their own to produce IDs in whatever shape their infrastructure
+// expects.
+type IDGenerator interface {
+	// Generate returns a new, unique trace ID.
+	Generate() string
+}
+
+// generator is the IDGenerator used by NewTraceID. It defaults to
+// UUIDv4Generator so that existing callers keep getting 36-byte UUIDs
+// without having to opt in.
+var generator IDGenerator = UUIDv4Generator{}
+
+// SetIDGenerator replaces the package-level IDGenerator used by NewTraceID,
+// WithTraceID, WithTestingTraceID, Handler and Transport.RoundTrip. Passing a
+// nil generator restores the default UUIDv4Generator.
+func SetIDGenerator(g IDGenerator) {
+	if g == nil {
+		g = UUIDv4Generator{}
+	}
+	generator = g
+}
+
+// UUIDv4Generator generates random UUIDv4 trace IDs, as NewTraceID always
+// did historically.
+type UUIDv4Generator struct{}
+
+// Generate implements IDGenerator.
+func (UUIDv4Generator) Generate() string {
+	return uuid.New().String()
+}
+
+// UUIDv7Generator generates time-ordered UUIDv7 trace IDs. Being sortable by
+// creation time, they are a better fit than UUIDv4 for use as a database
+// index.
+type UUIDv7Generator struct{}
+
+// Generate implements IDGenerator.
+func (UUIDv7Generator) Generate() string {
+	var b [16]byte
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x70 // version 7
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// XIDGenerator generates 20-character, k-sortable trace IDs using
+// github.com/rs/xid, the same scheme used by projects like rs/xid itself.
+// IDs are shorter than UUIDs and still sortable by creation time.
+type XIDGenerator struct{}
+
+// Generate implements IDGenerator.
+func (XIDGenerator) Generate() string {
+	return xid.New().String()
+}
+
+// ValidateTraceID is called, when non-nil, by Handler whenever an inbound
+// request carries a trace ID via TraceIDHeader or TraceParentHeader. If it
+// returns a non-nil error the inbound ID is discarded and a new one is
+// generated instead, so that servers do not end up trusting arbitrary
+// header values from callers. It is nil by default, meaning any inbound ID
+// is accepted as-is.
+var ValidateTraceID func(id string) error
@@ -0,0 +1,101 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package ctxtrace_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/canonical/ctxtrace"
+)
+
+func TestSetIDGeneratorUsesGivenGenerator(t *testing.T) {
+	c := qt.New(t)
+	defer ctxtrace.SetIDGenerator(nil)
+
+	ctxtrace.SetIDGenerator(ctxtrace.XIDGenerator{})
+	c.Assert(len(ctxtrace.NewTraceID()), qt.Equals, 20)
+}
+
+func TestSetIDGeneratorNilRestoresDefault(t *testing.T) {
+	c := qt.New(t)
+	ctxtrace.SetIDGenerator(ctxtrace.XIDGenerator{})
+	ctxtrace.SetIDGenerator(nil)
+	c.Assert(len(ctxtrace.NewTraceID()), qt.Equals, 36)
+}
+
+func TestUUIDv7GeneratorProducesDistinctIDs(t *testing.T) {
+	c := qt.New(t)
+	gen := ctxtrace.UUIDv7Generator{}
+	c.Assert(gen.Generate(), qt.Not(qt.Equals), gen.Generate())
+}
+
+func TestValidateTraceIDHookReturnsError(t *testing.T) {
+	c := qt.New(t)
+	ctxtrace.ValidateTraceID = func(id string) error {
+		return errors.New("always invalid")
+	}
+	defer func() { ctxtrace.ValidateTraceID = nil }()
+
+	c.Assert(ctxtrace.ValidateTraceID("anything"), qt.Not(qt.IsNil))
+}
+
+// TestHandlerDiscardsInboundIDRejectedByValidateTraceID drives
+// ValidateTraceID through Handler end to end: an inbound X-Trace-Id that
+// fails validation must be discarded and replaced with a freshly generated
+// one, not trusted as-is.
+func TestHandlerDiscardsInboundIDRejectedByValidateTraceID(t *testing.T) {
+	c := qt.New(t)
+	ctxtrace.ValidateTraceID = func(id string) error {
+		if id == "attacker-supplied" {
+			return errors.New("rejected")
+		}
+		return nil
+	}
+	defer func() { ctxtrace.ValidateTraceID = nil }()
+
+	srv := httptest.NewServer(ctxtrace.Handler(http.HandlerFunc(dummyHandler)))
+	defer srv.Close()
+
+	request, err := http.NewRequest("GET", srv.URL, nil)
+	c.Assert(err, qt.IsNil)
+	request.Header.Set(ctxtrace.TraceIDHeader, "attacker-supplied")
+
+	response, err := http.DefaultClient.Do(request)
+	c.Assert(err, qt.IsNil)
+	c.Assert(response.Header.Get(ctxtrace.TraceIDHeader), qt.Not(qt.Equals), "attacker-supplied")
+	c.Assert(response.Header.Get(ctxtrace.TraceIDHeader), qt.Not(qt.Equals), "")
+}
+
+// TestNewRequestIDDiscardsInboundIDRejectedByValidateTraceID is the same
+// check against the NewRequestID constructor chunk0-4 introduced, so the
+// validation hook is proven to apply there too, not just the legacy Handler
+// shim.
+func TestNewRequestIDDiscardsInboundIDRejectedByValidateTraceID(t *testing.T) {
+	c := qt.New(t)
+	ctxtrace.ValidateTraceID = func(id string) error {
+		if id == "attacker-supplied" {
+			return errors.New("rejected")
+		}
+		return nil
+	}
+	defer func() { ctxtrace.ValidateTraceID = nil }()
+
+	middleware := ctxtrace.NewRequestID()
+	srv := httptest.NewServer(middleware(http.HandlerFunc(dummyHandler)))
+	defer srv.Close()
+
+	request, err := http.NewRequest("GET", srv.URL, nil)
+	c.Assert(err, qt.IsNil)
+	request.Header.Set(ctxtrace.TraceIDHeader, "attacker-supplied")
+
+	response, err := http.DefaultClient.Do(request)
+	c.Assert(err, qt.IsNil)
+	c.Assert(response.Header.Get(ctxtrace.TraceIDHeader), qt.Not(qt.Equals), "attacker-supplied")
+	c.Assert(response.Header.Get(ctxtrace.TraceIDHeader), qt.Not(qt.Equals), "")
+}
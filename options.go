@@ -0,0 +1,195 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package ctxtrace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// config holds the options accepted by NewRequestID.
+type config struct {
+	headerName      string
+	fallbackHeader  string
+	generator       IDGenerator
+	responseHeader  bool
+	capturedHeaders *[]string
+}
+
+// Option configures the middleware returned by NewRequestID.
+type Option func(*config)
+
+// WithHeaderName sets the request/response header name used to carry the
+// trace ID, instead of the default TraceIDHeader. Useful for deployments
+// that already emit their own header, e.g. "X-Request-Id".
+func WithHeaderName(name string) Option {
+	return func(c *config) { c.headerName = name }
+}
+
+// WithFallbackHeader sets a legacy header name that is consulted when the
+// primary header (TraceIDHeader, or the one set with WithHeaderName) is
+// absent from the incoming request, e.g. accepting "X-Request-Id" first and
+// falling back to an older "X-Smallstep-Id" convention.
+func WithFallbackHeader(name string) Option {
+	return func(c *config) { c.fallbackHeader = name }
+}
+
+// WithGenerator sets the IDGenerator used to create a trace ID when the
+// incoming request carries none, instead of the package-level generator
+// configured with SetIDGenerator. Without this option, the middleware reads
+// the package-level generator fresh on every request, so a later
+// SetIDGenerator call is picked up immediately, just as it is by NewTraceID,
+// WithTraceID, WithTestingTraceID and Transport.RoundTrip.
+func WithGenerator(g IDGenerator) Option {
+	return func(c *config) { c.generator = g }
+}
+
+// WithResponseHeader controls whether the trace ID header is written back
+// to the response. It defaults to true; pass false to only propagate the ID
+// through the context without exposing it to clients.
+func WithResponseHeader(enabled bool) Option {
+	return func(c *config) { c.responseHeader = enabled }
+}
+
+// WithCapturedHeaderNames sets the list of request header names this
+// middleware captures into the context, overriding the package-level list
+// configured with SetCapturedHeaders for this middleware only. This allows
+// two NewRequestID middlewares in the same process to capture different
+// headers. Passing an empty, non-nil slice disables capturing entirely for
+// this middleware.
+func WithCapturedHeaderNames(names []string) Option {
+	return func(c *config) { c.capturedHeaders = &names }
+}
+
+// NewRequestID returns request-ID middleware compatible with the chi
+// middleware signature (func(http.Handler) http.Handler), so it drops
+// straight into r.Use(...). With no options it behaves like Handler: it
+// reads TraceIDHeader (honoring a W3C traceparent header when present),
+// generates a new ID with the package's configured IDGenerator when
+// neither is present, attaches it and its SpanContext to the context, and
+// sets it on the response.
+func NewRequestID(opts ...Option) func(http.Handler) http.Handler {
+	cfg := config{
+		headerName:     TraceIDHeader,
+		responseHeader: true,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceID := cfg.extractTraceID(r)
+			if traceID != "" && ValidateTraceID != nil && ValidateTraceID(traceID) != nil {
+				traceID = ""
+			}
+
+			sc, err := cfg.extractSpanContext(r)
+			if err == nil && ValidateTraceID != nil && ValidateTraceID(sc.TraceID) != nil {
+				err = fmt.Errorf("ctxtrace: traceparent trace id rejected by ValidateTraceID")
+			}
+			switch {
+			case err == nil:
+				// traceparent is present and valid: it is the source of truth
+				// for the trace ID, keeping the configured header in sync.
+				traceID = sc.TraceID
+			case traceID != "":
+				sc = spanContextFromTraceID(traceID)
+			default:
+				traceID = cfg.generate()
+				sc = spanContextFromTraceID(traceID)
+			}
+			sc.TraceState = r.Header.Get(TraceStateHeader)
+
+			if cfg.responseHeader {
+				w.Header().Set(cfg.headerName, traceID)
+				if cfg.headerName == TraceIDHeader {
+					w.Header().Set(TraceParentHeader, FormatTraceParent(sc))
+					if sc.TraceState != "" {
+						w.Header().Set(TraceStateHeader, sc.TraceState)
+					}
+				}
+			}
+
+			ctx := WithTraceID(r.Context(), traceID)
+			ctx = WithSpanContext(ctx, sc)
+			names := capturedHeaderNames
+			if cfg.capturedHeaders != nil {
+				names = *cfg.capturedHeaders
+			}
+			if captured := captureHeaders(r.Header, names); captured != nil {
+				ctx = WithCapturedHeaders(ctx, captured)
+			}
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// generate returns a new trace ID using cfg.generator if WithGenerator was
+// given, or the package-level generator (read fresh, not cached) otherwise.
+func (c config) generate() string {
+	if c.generator != nil {
+		return c.generator.Generate()
+	}
+	return generator.Generate()
+}
+
+// extractTraceID returns the trace ID carried by the configured header,
+// falling back to the fallback header, or "" if neither is present.
+func (c config) extractTraceID(r *http.Request) string {
+	if id := r.Header.Get(c.headerName); id != "" {
+		return id
+	}
+	if c.fallbackHeader != "" {
+		return r.Header.Get(c.fallbackHeader)
+	}
+	return ""
+}
+
+// extractSpanContext parses the inbound traceparent header, when the
+// configured header is the canonical TraceIDHeader. A custom header name is
+// treated as an opaque legacy request ID that does not participate in W3C
+// Trace Context negotiation.
+func (c config) extractSpanContext(r *http.Request) (SpanContext, error) {
+	if c.headerName != TraceIDHeader {
+		return SpanContext{}, fmt.Errorf("ctxtrace: traceparent not applicable to custom header %q", c.headerName)
+	}
+	return ParseTraceParent(r.Header.Get(TraceParentHeader))
+}
+
+// traceIDFromRequest returns the effective trace ID carried by req, honoring
+// the canonical TraceIDHeader and the W3C traceparent header, preferring a
+// valid traceparent. Handler and Transport do not call it.
+func traceIDFromRequest(req *http.Request) string {
+	if sc, err := ParseTraceParent(req.Header.Get(TraceParentHeader)); err == nil {
+		return sc.TraceID
+	}
+	return req.Header.Get(TraceIDHeader)
+}
+
+// setTraceHeader sets TraceIDHeader and, deriving a child SpanContext from
+// whatever is stored in ctx, the traceparent/tracestate headers on req.
+func setTraceHeader(ctx context.Context, req *http.Request) {
+	traceID := TraceIDFromContext(ctx)
+	sc, ok := SpanContextFromContext(ctx)
+	switch {
+	case ok:
+		sc = nextSpanContext(sc)
+	case traceID != "":
+		sc = spanContextFromTraceID(traceID)
+	default:
+		traceID = NewTraceID()
+		sc = spanContextFromTraceID(traceID)
+	}
+	if traceID == "" {
+		traceID = sc.TraceID
+	}
+
+	req.Header.Set(TraceIDHeader, traceID)
+	req.Header.Set(TraceParentHeader, FormatTraceParent(sc))
+	if sc.TraceState != "" {
+		req.Header.Set(TraceStateHeader, sc.TraceState)
+	}
+}
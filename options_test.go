@@ -0,0 +1,86 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package ctxtrace_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/canonical/ctxtrace"
+)
+
+func TestNewRequestIDWithHeaderName(t *testing.T) {
+	c := qt.New(t)
+	middleware := ctxtrace.NewRequestID(ctxtrace.WithHeaderName("X-Request-Id"))
+	srv := httptest.NewServer(middleware(http.HandlerFunc(dummyHandler)))
+	defer srv.Close()
+
+	response, err := http.DefaultClient.Get(srv.URL)
+	c.Assert(err, qt.IsNil)
+	c.Assert(response.Header.Get("X-Request-Id"), qt.Not(qt.Equals), "")
+	c.Assert(response.Header.Get(ctxtrace.TraceIDHeader), qt.Equals, "")
+}
+
+func TestNewRequestIDWithFallbackHeader(t *testing.T) {
+	c := qt.New(t)
+	middleware := ctxtrace.NewRequestID(
+		ctxtrace.WithHeaderName("X-Request-Id"),
+		ctxtrace.WithFallbackHeader("X-Smallstep-Id"),
+	)
+	srv := httptest.NewServer(middleware(http.HandlerFunc(dummyHandler)))
+	defer srv.Close()
+
+	request, err := http.NewRequest("GET", srv.URL, nil)
+	c.Assert(err, qt.IsNil)
+	request.Header.Set("X-Smallstep-Id", "legacy-id")
+
+	response, err := http.DefaultClient.Do(request)
+	c.Assert(err, qt.IsNil)
+	c.Assert(response.Header.Get("X-Request-Id"), qt.Equals, "legacy-id")
+}
+
+func TestNewRequestIDWithResponseHeaderDisabled(t *testing.T) {
+	c := qt.New(t)
+	middleware := ctxtrace.NewRequestID(ctxtrace.WithResponseHeader(false))
+	srv := httptest.NewServer(middleware(http.HandlerFunc(dummyHandler)))
+	defer srv.Close()
+
+	response, err := http.DefaultClient.Get(srv.URL)
+	c.Assert(err, qt.IsNil)
+	c.Assert(response.Header.Get(ctxtrace.TraceIDHeader), qt.Equals, "")
+}
+
+func TestNewRequestIDWithGenerator(t *testing.T) {
+	c := qt.New(t)
+	middleware := ctxtrace.NewRequestID(ctxtrace.WithGenerator(ctxtrace.XIDGenerator{}))
+	srv := httptest.NewServer(middleware(http.HandlerFunc(dummyHandler)))
+	defer srv.Close()
+
+	response, err := http.DefaultClient.Get(srv.URL)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(response.Header.Get(ctxtrace.TraceIDHeader)), qt.Equals, 20)
+}
+
+// TestNewRequestIDWithoutGeneratorOptionPicksUpLaterSetIDGenerator verifies
+// that a middleware built without WithGenerator keeps tracking the
+// package-level generator, the same way NewTraceID, WithTraceID,
+// WithTestingTraceID and Transport.RoundTrip do, rather than freezing
+// whatever generator was configured at construction time.
+func TestNewRequestIDWithoutGeneratorOptionPicksUpLaterSetIDGenerator(t *testing.T) {
+	c := qt.New(t)
+	defer ctxtrace.SetIDGenerator(nil)
+
+	middleware := ctxtrace.NewRequestID()
+	srv := httptest.NewServer(middleware(http.HandlerFunc(dummyHandler)))
+	defer srv.Close()
+
+	ctxtrace.SetIDGenerator(ctxtrace.XIDGenerator{})
+
+	response, err := http.DefaultClient.Get(srv.URL)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(response.Header.Get(ctxtrace.TraceIDHeader)), qt.Equals, 20)
+}
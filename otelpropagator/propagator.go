@@ -0,0 +1,56 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package otelpropagator provides an OpenTelemetry TextMapPropagator that
+// carries the ctxtrace trace ID, so that services with an OTel
+// TracerProvider already wired up can fold it into their propagation chain
+// without ctxtrace itself depending on OpenTelemetry.
+package otelpropagator
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/canonical/ctxtrace"
+)
+
+// Propagator implements the OpenTelemetry TextMapPropagator interface,
+// reading and writing the ctxtrace trace ID via ctxtrace.TraceIDHeader on
+// any propagation.TextMapCarrier. Register it alongside the usual
+// propagators so the trace ID travels through gRPC metadata, Kafka headers,
+// or anything else with an OTel carrier adapter:
+//
+//	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+//		propagation.TraceContext{},
+//		otelpropagator.Propagator{},
+//	))
+type Propagator struct{}
+
+var _ propagation.TextMapPropagator = Propagator{}
+
+// Inject implements propagation.TextMapPropagator, writing the trace ID
+// carried in ctx, if any, to carrier.
+func (Propagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	traceID := ctxtrace.TraceIDFromContext(ctx)
+	if traceID == "" {
+		return
+	}
+	carrier.Set(ctxtrace.TraceIDHeader, traceID)
+}
+
+// Extract implements propagation.TextMapPropagator, attaching the trace ID
+// found in carrier, if any, to the returned context.
+func (Propagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	traceID := carrier.Get(ctxtrace.TraceIDHeader)
+	if traceID == "" {
+		return ctx
+	}
+	return ctxtrace.WithTraceID(ctx, traceID)
+}
+
+// Fields implements propagation.TextMapPropagator, reporting the carrier
+// keys this propagator reads and writes.
+func (Propagator) Fields() []string {
+	return []string{ctxtrace.TraceIDHeader}
+}
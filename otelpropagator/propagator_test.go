@@ -0,0 +1,48 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package otelpropagator_test
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/canonical/ctxtrace"
+	"github.com/canonical/ctxtrace/otelpropagator"
+)
+
+func TestInject(t *testing.T) {
+	c := qt.New(t)
+	ctx := ctxtrace.WithTraceID(context.Background(), "abc")
+	carrier := propagation.MapCarrier{}
+
+	otelpropagator.Propagator{}.Inject(ctx, carrier)
+
+	c.Assert(carrier.Get(ctxtrace.TraceIDHeader), qt.Equals, "abc")
+}
+
+func TestInjectEmptyTraceID(t *testing.T) {
+	c := qt.New(t)
+	carrier := propagation.MapCarrier{}
+
+	otelpropagator.Propagator{}.Inject(context.Background(), carrier)
+
+	c.Assert(carrier.Get(ctxtrace.TraceIDHeader), qt.Equals, "")
+}
+
+func TestExtract(t *testing.T) {
+	c := qt.New(t)
+	carrier := propagation.MapCarrier{ctxtrace.TraceIDHeader: "abc"}
+
+	ctx := otelpropagator.Propagator{}.Extract(context.Background(), carrier)
+
+	c.Assert(ctxtrace.TraceIDFromContext(ctx), qt.Equals, "abc")
+}
+
+func TestFields(t *testing.T) {
+	c := qt.New(t)
+	c.Assert(otelpropagator.Propagator{}.Fields(), qt.DeepEquals, []string{ctxtrace.TraceIDHeader})
+}
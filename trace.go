@@ -10,8 +10,6 @@ import (
 	"context"
 	"net/http"
 	"strings"
-
-	"github.com/google/uuid"
 )
 
 const (
@@ -29,9 +27,10 @@ const (
 
 type traceIDContextKey struct{}
 
-// NewTraceID generates a new uuid v4 trace ID string.
+// NewTraceID generates a new trace ID string using the configured
+// IDGenerator, a UUIDv4Generator by default. See SetIDGenerator to change it.
 func NewTraceID() string {
-	return uuid.New().String()
+	return generator.Generate()
 }
 
 // WithTraceID attaches the given ID to the given context. This ID will be
@@ -77,16 +76,17 @@ func TraceIDFromContext(ctx context.Context) string {
 }
 
 // Handler is a handler that get the trace id from the request, if empty generate
-// a new one, put it in the context and set it on the response.
+// a new one, put it in the context and set it on the response. It also
+// understands the W3C traceparent/tracestate headers: when a traceparent is
+// present its trace ID takes precedence over X-Trace-Id, and a SpanContext
+// is attached to the context alongside the trace ID so that ctxtrace-aware
+// tracers (OpenTelemetry, Jaeger, Zipkin, ...) can interoperate.
+//
+// Handler is a thin shim over NewRequestID kept for backward compatibility;
+// new code that needs to customize the header name or ID generator should
+// call NewRequestID directly.
 func Handler(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		traceID := r.Header.Get(TraceIDHeader)
-		if traceID == "" {
-			traceID = NewTraceID()
-		}
-		w.Header().Set(TraceIDHeader, traceID)
-		h.ServeHTTP(w, r.WithContext(WithTraceID(r.Context(), traceID)))
-	})
+	return NewRequestID()(h)
 }
 
 // Transport implements http.RoundTripper. It transmits the trace id from the
@@ -107,33 +107,57 @@ type Transport struct {
 	// Transport.RoundTrip has enriched the incoming request with the trace id
 	// header.
 	RoundTripper http.RoundTripper
+
+	// CapturedHeaders lists the names of headers, previously captured into
+	// the context by Handler, that should be re-emitted on the outbound
+	// request. A nil slice re-emits every header captured in the context.
+	CapturedHeaders []string
 }
 
 // RoundTrip implements http.RoundTripper interface to transfer the trace id, or
 // creating a new one if it is empty, from the incoming request to the following
 // http.RoundTripper. It is followed by http.DefaultTransport or a given RoundTripper
-// when declaring Transport.
+// when declaring Transport. A new child span ID is generated for every outbound
+// hop, preserving the trace ID and emitting both the legacy X-Trace-Id header
+// and the W3C traceparent/tracestate headers.
 func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	rt := t.RoundTripper
 	if rt == nil {
 		rt = http.DefaultTransport
 	}
 
-	if req.Header.Get(TraceIDHeader) != "" {
-		// If the request already has a trace header don't overwrite it.
+	if req.Header.Get(TraceIDHeader) != "" && req.Header.Get(TraceParentHeader) != "" {
+		// If the request already has both trace headers don't overwrite them.
 		return rt.RoundTrip(req)
 	}
 
 	newReq := *req
-	newReq.Header = make(http.Header, len(req.Header) + 1)
+	newReq.Header = make(http.Header, len(req.Header)+2)
 	// Copy headers from old to the new request.
 	for k, v := range req.Header {
 		newReq.Header[k] = v
 	}
-	traceID := TraceIDFromContext(newReq.Context())
-	if traceID == "" {
-		traceID = NewTraceID()
+
+	setTraceHeader(newReq.Context(), &newReq)
+	for name, value := range t.selectCapturedHeaders(newReq.Context()) {
+		newReq.Header.Set(name, value)
 	}
-	newReq.Header.Set(TraceIDHeader, traceID)
 	return rt.RoundTrip(&newReq)
 }
+
+// selectCapturedHeaders returns the captured headers from ctx that should be
+// re-emitted on the outbound request, filtered down to t.CapturedHeaders
+// when it is set.
+func (t Transport) selectCapturedHeaders(ctx context.Context) map[string]string {
+	captured := CapturedHeadersFromContext(ctx)
+	if t.CapturedHeaders == nil {
+		return captured
+	}
+	selected := make(map[string]string, len(t.CapturedHeaders))
+	for _, name := range t.CapturedHeaders {
+		if v, ok := captured[name]; ok {
+			selected[name] = v
+		}
+	}
+	return selected
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package ctxtrace
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// TraceParentHeader holds the header key used to propagate a W3C Trace
+	// Context traceparent value, as defined by
+	// https://www.w3.org/TR/trace-context/#traceparent-header.
+	TraceParentHeader = "traceparent"
+	// TraceStateHeader holds the header key used to propagate a W3C Trace
+	// Context tracestate value.
+	TraceStateHeader = "tracestate"
+
+	traceParentVersion = "00"
+	traceIDHexLen      = 32
+	spanIDHexLen       = 16
+)
+
+type spanContextContextKey struct{}
+
+// SpanContext holds the W3C Trace Context fields that travel alongside the
+// existing opaque trace ID. TraceID and SpanID are lower-case hex strings of
+// 32 and 16 characters respectively, matching the traceparent wire format.
+type SpanContext struct {
+	// TraceID is the 16-byte (32 hex character) identifier shared by every
+	// span in a trace.
+	TraceID string
+	// ParentSpanID is the 8-byte (16 hex character) identifier of the span
+	// that this one was created from. It is empty for the first hop.
+	ParentSpanID string
+	// SpanID is the 8-byte (16 hex character) identifier of the current span.
+	SpanID string
+	// Sampled reports whether this trace should be sampled, carried over the
+	// wire as the least significant bit of the traceparent flags byte.
+	Sampled bool
+	// TraceState carries vendor-specific trace information as described by
+	// the tracestate header. It is propagated verbatim.
+	TraceState string
+}
+
+// WithSpanContext attaches the given SpanContext to the given context.
+func WithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the SpanContext stored in ctx, if any, and
+// whether one was found.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// newSpanID generates a new random 8-byte span ID, hex-encoded.
+func newSpanID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// ParseTraceParent parses a traceparent header value of the form
+// "version-traceid-parentid-flags" (e.g. "00-<32 hex>-<16 hex>-01"). It
+// validates the version byte and the hex lengths of the trace and parent
+// span IDs but, per the W3C spec, does not reject unknown versions outright;
+// it only rejects values that are structurally invalid.
+func ParseTraceParent(header string) (SpanContext, error) {
+	parts := strings.Split(header, "-")
+	if len(parts) < 4 {
+		return SpanContext{}, fmt.Errorf("ctxtrace: invalid traceparent %q: expected 4 fields", header)
+	}
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 {
+		return SpanContext{}, fmt.Errorf("ctxtrace: invalid traceparent %q: invalid version", header)
+	}
+	if _, err := hex.DecodeString(version); err != nil {
+		return SpanContext{}, fmt.Errorf("ctxtrace: invalid traceparent %q: version is not hex: %w", header, err)
+	}
+	if len(traceID) != traceIDHexLen {
+		return SpanContext{}, fmt.Errorf("ctxtrace: invalid traceparent %q: invalid trace id length", header)
+	}
+	if len(parentID) != spanIDHexLen {
+		return SpanContext{}, fmt.Errorf("ctxtrace: invalid traceparent %q: invalid parent id length", header)
+	}
+	if len(flags) != 2 {
+		return SpanContext{}, fmt.Errorf("ctxtrace: invalid traceparent %q: invalid flags length", header)
+	}
+	if _, err := hex.DecodeString(traceID); err != nil {
+		return SpanContext{}, fmt.Errorf("ctxtrace: invalid traceparent %q: trace id is not hex: %w", header, err)
+	}
+	if _, err := hex.DecodeString(parentID); err != nil {
+		return SpanContext{}, fmt.Errorf("ctxtrace: invalid traceparent %q: parent id is not hex: %w", header, err)
+	}
+	flagsByte, err := hex.DecodeString(flags)
+	if err != nil {
+		return SpanContext{}, fmt.Errorf("ctxtrace: invalid traceparent %q: flags are not hex: %w", header, err)
+	}
+	return SpanContext{
+		TraceID:      traceID,
+		ParentSpanID: parentID,
+		SpanID:       newSpanID(),
+		Sampled:      flagsByte[0]&0x01 == 1,
+	}, nil
+}
+
+// FormatTraceParent renders sc as a traceparent header value, using sc.SpanID
+// as the outgoing span and flipping the sampled bit from sc.Sampled.
+func FormatTraceParent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("%s-%s-%s-%s", traceParentVersion, sc.TraceID, sc.SpanID, flags)
+}
+
+// nextSpanContext derives the SpanContext for an outbound hop from sc,
+// preserving the trace ID and moving the current span ID into the parent
+// slot, generating a fresh span ID for the new hop.
+func nextSpanContext(sc SpanContext) SpanContext {
+	sc.ParentSpanID = sc.SpanID
+	sc.SpanID = newSpanID()
+	return sc
+}
+
+// spanContextFromTraceID builds a fresh root SpanContext from a plain trace
+// ID, hashing it with traceIDToHex into the 32 hex characters a traceparent
+// trace ID requires. This is used to bridge the legacy X-Trace-Id-only case
+// into the W3C representation.
+func spanContextFromTraceID(traceID string) SpanContext {
+	return SpanContext{
+		TraceID: traceIDToHex(traceID),
+		SpanID:  newSpanID(),
+		Sampled: true,
+	}
+}
+
+// traceIDToHex coerces an arbitrary trace ID string into a 32 hex character
+// identifier suitable for the traceparent trace id field, so that opaque
+// (e.g. UUID or testing-prefixed) trace IDs can still be carried over the
+// wire. It hashes the whole input with sha256, truncated to 16 bytes, rather
+// than slicing it, so that two IDs differing only after the first 16
+// characters don't collapse onto the same traceparent trace ID.
+func traceIDToHex(traceID string) string {
+	sum := sha256.Sum256([]byte(traceID))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package ctxtrace_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/canonical/ctxtrace"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	c := qt.New(t)
+	sc, err := ctxtrace.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	c.Assert(err, qt.IsNil)
+	c.Assert(sc.TraceID, qt.Equals, "4bf92f3577b34da6a3ce929d0e0e4736")
+	c.Assert(sc.ParentSpanID, qt.Equals, "00f067aa0ba902b7")
+	c.Assert(sc.SpanID, qt.Not(qt.Equals), "")
+	c.Assert(sc.Sampled, qt.Equals, true)
+}
+
+func TestParseTraceParentInvalid(t *testing.T) {
+	c := qt.New(t)
+	_, err := ctxtrace.ParseTraceParent("not-a-traceparent")
+	c.Assert(err, qt.Not(qt.IsNil))
+}
+
+func TestFormatTraceParentRoundTrip(t *testing.T) {
+	c := qt.New(t)
+	sc := ctxtrace.SpanContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00f067aa0ba902b7",
+		Sampled: true,
+	}
+	header := ctxtrace.FormatTraceParent(sc)
+	parsed, err := ctxtrace.ParseTraceParent(header)
+	c.Assert(err, qt.IsNil)
+	c.Assert(parsed.TraceID, qt.Equals, sc.TraceID)
+	c.Assert(parsed.ParentSpanID, qt.Equals, sc.SpanID)
+	c.Assert(parsed.Sampled, qt.Equals, sc.Sampled)
+}
+
+func TestHandlerPopulatesTraceIDFromTraceParent(t *testing.T) {
+	c := qt.New(t)
+	srv := httptest.NewServer(ctxtrace.Handler(http.HandlerFunc(dummyHandler)))
+	defer srv.Close()
+
+	request, err := http.NewRequest("GET", srv.URL, nil)
+	c.Assert(err, qt.IsNil)
+	request.Header.Set(ctxtrace.TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	response, err := http.DefaultClient.Do(request)
+	c.Assert(err, qt.IsNil)
+	c.Assert(response.Header.Get(ctxtrace.TraceIDHeader), qt.Equals, "4bf92f3577b34da6a3ce929d0e0e4736")
+	c.Assert(response.Header.Get(ctxtrace.TraceParentHeader), qt.Not(qt.Equals), "")
+}
+
+func TestHandlerDoesNotCollideOpaqueTraceIDsSharingAPrefix(t *testing.T) {
+	c := qt.New(t)
+	srv := httptest.NewServer(ctxtrace.Handler(http.HandlerFunc(dummyHandler)))
+	defer srv.Close()
+
+	get := func(traceID string) string {
+		request, err := http.NewRequest("GET", srv.URL, nil)
+		c.Assert(err, qt.IsNil)
+		request.Header.Set(ctxtrace.TraceIDHeader, traceID)
+		response, err := http.DefaultClient.Do(request)
+		c.Assert(err, qt.IsNil)
+		parent := response.Header.Get(ctxtrace.TraceParentHeader)
+		return strings.Split(parent, "-")[1]
+	}
+
+	// Both IDs share their first 16 characters; a naive truncation of the
+	// hex-encoded string would collapse them onto the same trace ID.
+	traceID1 := get("123e4567-e89b-42d3-a456-426614174000")
+	traceID2 := get("123e4567-e89b-42d3-ffff-ffffffffffff")
+	c.Assert(traceID1, qt.Not(qt.Equals), traceID2)
+}
+
+func TestTransportRoundTripGeneratesChildSpanPerHopAndPreservesTraceID(t *testing.T) {
+	c := qt.New(t)
+	srv := httptest.NewServer(ctxtrace.Handler(http.HandlerFunc(dummyHandler)))
+	defer srv.Close()
+
+	sc := ctxtrace.SpanContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00f067aa0ba902b7",
+		Sampled: true,
+	}
+	ctx := ctxtrace.WithSpanContext(context.Background(), sc)
+	ctx = ctxtrace.WithTraceID(ctx, sc.TraceID)
+
+	client := http.Client{Transport: ctxtrace.Transport{}}
+	request, err := http.NewRequest("GET", srv.URL, nil)
+	c.Assert(err, qt.IsNil)
+	request = request.WithContext(ctx)
+
+	response, err := client.Do(request)
+	c.Assert(err, qt.IsNil)
+
+	parts := strings.Split(response.Request.Header.Get(ctxtrace.TraceParentHeader), "-")
+	c.Assert(parts, qt.HasLen, 4)
+	c.Assert(parts[1], qt.Equals, sc.TraceID)
+	c.Assert(parts[2], qt.Not(qt.Equals), sc.SpanID)
+	c.Assert(response.Request.Header.Get(ctxtrace.TraceIDHeader), qt.Equals, sc.TraceID)
+}